@@ -3,72 +3,194 @@ package main
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
-	"io"
 	"log"
-	"net/http"
 	"os"
+	"os/signal"
 	"path"
+	"sync"
 	"text/template"
-	"time"
 
 	cloudinary "github.com/cloudinary/cloudinary-go"
 	"github.com/cloudinary/cloudinary-go/api"
 	"github.com/cloudinary/cloudinary-go/api/admin"
-	"github.com/dustin/go-humanize"
+	"github.com/cloudinary/cloudinary-go/asset"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/jlisicki/cloudinary-dump/manifest"
+	"github.com/jlisicki/cloudinary-dump/progress"
+	"github.com/jlisicki/cloudinary-dump/storage"
+	"github.com/jlisicki/cloudinary-dump/transfer"
 )
 
+// pipelineBuffer bounds how many listed-but-not-yet-downloaded assets (and
+// queued-but-not-yet-started jobs) may sit in memory at once, so listing
+// millions of assets can run well ahead of the much slower download stage
+// without buffering the whole account in memory.
+const pipelineBuffer = 64
+
 var (
 	printHelp          = flag.Bool("h", false, "Print help")
 	cloudinaryURL      = flag.String("u", "", "Cloudinary URL with credentials")
-	targetFolder       = flag.String("d", "dump", "Target folder for downloaded assets")
+	targetFolder       = flag.String("d", "dump", "Local folder used to track download state, and the default storage target if -target is not set")
+	target             = flag.String("target", "", "Storage target to write downloaded assets to: a local path, or an s3://bucket/prefix, gs://bucket/prefix or b2://bucket/prefix URL. Defaults to -d")
 	targetNameTemplate = flag.String("t", "{{.PublicID}}", "Template to produce target file name. Uses go template engine with provided struct defined in https://pkg.go.dev/github.com/cloudinary/cloudinary-go@v1.1.0/api/admin#AssetResult")
 	concurrency        = flag.Int("c", 5, "How many concurrent connections to use to download assets")
+	resume             = flag.Bool("resume", false, "Skip assets already recorded in the target folder's manifest and only (re-)download new or changed ones")
+	prune              = flag.Bool("prune", false, "With -resume, delete assets recorded in the manifest that no longer exist remotely")
+	silent             = flag.Bool("silent", false, "Suppress all progress output")
+	noProgressBars     = flag.Bool("no-progress", false, "Report progress as plain log lines instead of live progress bars")
+	derived            = flag.Bool("derived", false, "Also download derived transformations of each asset")
+)
+
+// resourceTypes and deliveryTypes are the combinations of asset and delivery
+// type enumerated when listing assets, so that video and raw assets, as well
+// as privately and authenticated-delivered ones, are picked up alongside
+// plain uploaded images.
+var (
+	resourceTypes = []api.AssetType{api.Image, api.Video, api.File}
+	deliveryTypes = []api.DeliveryType{api.Upload, api.Private, api.Authenticated}
 )
 
-type (
-	Downloader struct {
-		CloudinaryURL                string
-		TargetFolder                 string
-		TargetFileNameTemplateString string
-		Concurrency                  int
+type Downloader struct {
+	CloudinaryURL                string
+	TargetFolder                 string
+	Target                       string
+	TargetFileNameTemplateString string
+	Concurrency                  int
+	Resume                       bool
+	Prune                        bool
+	Silent                       bool
+	NoProgressBars               bool
+	Derived                      bool
 
-		cld                    *cloudinary.Cloudinary
-		targetFileNameTemplate *template.Template
+	cld                    *cloudinary.Cloudinary
+	targetFileNameTemplate *template.Template
+}
+
+// streamAssets lists every asset across all resource and delivery types
+// (images, videos, raw files; uploaded, private and authenticated delivery),
+// sending each one to out as soon as its listing page arrives rather than
+// buffering the whole account in memory first.
+func (d *Downloader) streamAssets(ctx context.Context, out chan<- api.BriefAssetResult) error {
+	for _, rt := range resourceTypes {
+		for _, dt := range deliveryTypes {
+			nextCursor := ""
+			for {
+				page, err := d.cld.Admin.Assets(ctx, admin.AssetsParams{
+					AssetType:    rt,
+					DeliveryType: dt.String(),
+					NextCursor:   nextCursor,
+					MaxResults:   1000,
+				})
+				if err != nil {
+					return err
+				}
+				if page.Error.Message != "" {
+					return fmt.Errorf("failed to list assets: %s", page.Error.Message)
+				}
+				nextCursor = page.NextCursor
+				for _, a := range page.Assets {
+					select {
+					case out <- a:
+					case <-ctx.Done():
+						return ctx.Err()
+					}
+				}
+				if page.NextCursor == "" {
+					break
+				}
+			}
+		}
 	}
-	assetList []api.BriefAssetResult
-)
+	return nil
+}
 
-func (a assetList) TotalSize() int {
-	totalSize := 0
-	for i := range a {
-		totalSize += a[i].Bytes
+// assetURL returns the URL an asset should be downloaded from. Plainly
+// uploaded assets use their public SecureURL; private and authenticated
+// assets require a freshly signed URL instead.
+func (d *Downloader) assetURL(a api.BriefAssetResult) (string, error) {
+	if a.Type == api.Upload.String() {
+		return a.SecureURL, nil
 	}
-	return totalSize
+	// The SDK's URL builder only appends a file extension if one is already
+	// part of the public ID (it doesn't know the asset's Format), so it has
+	// to be added here or the signed URL 404s.
+	publicID := a.PublicID
+	if a.Format != "" {
+		publicID += "." + a.Format
+	}
+	var built *asset.Asset
+	var err error
+	switch a.AssetType {
+	case string(api.Video):
+		built, err = d.cld.Video(publicID)
+	case string(api.File):
+		built, err = d.cld.File(publicID)
+	default:
+		built, err = d.cld.Image(publicID)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to build URL for %s: %w", a.PublicID, err)
+	}
+	built.DeliveryType = api.DeliveryType(a.Type)
+	built.Version = a.Version
+	built.Config.URL.SignURL = true
+	return built.String()
 }
 
-func (d *Downloader) collectAllAssets(ctx context.Context) (assetList, error) {
-	nextCursor := ""
-	result := []api.BriefAssetResult{}
-	for {
-		page, err := d.cld.Admin.Assets(ctx, admin.AssetsParams{
-			NextCursor: nextCursor,
-			MaxResults: 1000,
-		})
+// derivedAssetResult is the subset of fields Cloudinary returns for each
+// entry of AssetResult.Derived.
+type derivedAssetResult struct {
+	ID             string `json:"id"`
+	Transformation string `json:"transformation"`
+	Format         string `json:"format"`
+	Bytes          int    `json:"bytes"`
+	SecureURL      string `json:"secure_url"`
+}
+
+// derivedJobs fetches the derived transformations of asset a and returns a
+// download job for each of them, keyed off baseKey so they land next to the
+// original asset.
+func (d *Downloader) derivedJobs(ctx context.Context, a api.BriefAssetResult, baseKey string) ([]transfer.Asset, error) {
+	full, err := d.cld.Admin.Asset(ctx, admin.AssetParams{
+		PublicID:     a.PublicID,
+		AssetType:    api.AssetType(a.AssetType),
+		DeliveryType: api.DeliveryType(a.Type),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch derived assets for %s: %w", a.PublicID, err)
+	}
+	if full.Error.Message != "" {
+		return nil, fmt.Errorf("failed to fetch derived assets for %s: %s", a.PublicID, full.Error.Message)
+	}
+	jobs := make([]transfer.Asset, 0, len(full.Derived))
+	for _, raw := range full.Derived {
+		b, err := json.Marshal(raw)
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("failed to decode derived asset for %s: %w", a.PublicID, err)
 		}
-		if page.Error.Message != "" {
-			log.Panic(page.Error.Message)
+		var dr derivedAssetResult
+		if err := json.Unmarshal(b, &dr); err != nil {
+			return nil, fmt.Errorf("failed to decode derived asset for %s: %w", a.PublicID, err)
 		}
-		nextCursor = page.NextCursor
-		result = append(result, page.Assets...)
-		if page.NextCursor == "" {
-			break
+		if dr.ID == "" || dr.SecureURL == "" {
+			continue
 		}
+		key := baseKey + "." + dr.ID
+		if dr.Format != "" {
+			key += "." + dr.Format
+		}
+		jobs = append(jobs, transfer.Asset{
+			Name: a.AssetID + "#" + dr.ID,
+			URL:  dr.SecureURL,
+			Key:  key,
+			Size: int64(dr.Bytes),
+		})
 	}
-	return result, nil
+	return jobs, nil
 }
 
 func (d *Downloader) init() error {
@@ -85,91 +207,199 @@ func (d *Downloader) init() error {
 	return nil
 }
 
-func (d *Downloader) downloadAsset(asset api.BriefAssetResult) error {
-	resp, err := http.Get(asset.SecureURL)
-	if err != nil {
-		return fmt.Errorf("failed to make request: %w", err)
-	}
-	defer resp.Body.Close()
+// targetFileName renders the configured name template for asset into a
+// storage key.
+func (d *Downloader) targetFileName(asset api.BriefAssetResult) (string, error) {
 	var fileName bytes.Buffer
 	if err := d.targetFileNameTemplate.Execute(&fileName, asset); err != nil {
-		return fmt.Errorf("failed to create file name: %w", err)
+		return "", fmt.Errorf("failed to create file name: %w", err)
 	}
-	fullFileName := path.Join(d.TargetFolder, fileName.String())
-	_, err = os.Stat(fullFileName)
-	if !os.IsNotExist(err) {
-		return fmt.Errorf("checking file absence failed: %w", err)
+	return fileName.String(), nil
+}
+
+// Dump all assets from cloudinary to the storage target (Target, or
+// TargetFolder if Target is empty).
+//
+// Listing, job preparation and transfer run as a pipeline of three stages
+// connected by bounded channels, instead of buffering the whole asset list
+// in memory before a transfer begins: assets are listed, turned into jobs
+// and downloaded concurrently, so an account with millions of assets never
+// needs to hold them all at once. The three stages run under a single
+// errgroup, so the first real error (a listing failure, a bad name
+// template, a cancelled context) cancels the others and is returned here; a
+// single asset failing to download is instead recorded in failed below and
+// does not abort the run.
+func (d *Downloader) Dump(ctx context.Context) error {
+	if err := d.init(); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(d.TargetFolder, 0o755); err != nil {
+		return fmt.Errorf("failed to create target folder: %w", err)
 	}
-	f, err := os.Create(fullFileName)
+	man, err := manifest.Load(path.Join(d.TargetFolder, manifest.FileName))
 	if err != nil {
-		return fmt.Errorf("failed to create file: %w", err)
+		return err
 	}
-	_, err = io.Copy(f, resp.Body)
+
+	targetURL := d.Target
+	if targetURL == "" {
+		targetURL = d.TargetFolder
+	}
+	store, err := storage.Open(targetURL)
 	if err != nil {
-		return fmt.Errorf("failed to copy data from network to files: %w", err)
+		return fmt.Errorf("failed to open storage target %q: %w", targetURL, err)
 	}
-	return nil
-}
 
-func (d *Downloader) worker(pending chan api.BriefAssetResult, completed chan api.BriefAssetResult) {
-	for asset := range pending {
-		err := d.downloadAsset(asset)
-		if err != nil {
-			log.Fatalf("failed to handle asset %s: %s", asset.AssetID, err)
+	tracker := progress.New(d.Silent, d.NoProgressBars)
+	defer tracker.Close()
+
+	g, gctx := errgroup.WithContext(ctx)
+
+	assets := make(chan api.BriefAssetResult, pipelineBuffer)
+	g.Go(func() error {
+		defer close(assets)
+		return d.streamAssets(gctx, assets)
+	})
+
+	var (
+		mu              sync.Mutex
+		byName          = map[string]api.BriefAssetResult{}
+		remotePublicIDs = map[string]struct{}{}
+		skipped         int
+	)
+	jobs := make(chan transfer.Asset, pipelineBuffer)
+	g.Go(func() error {
+		defer close(jobs)
+		for a := range assets {
+			mu.Lock()
+			remotePublicIDs[a.PublicID] = struct{}{}
+			mu.Unlock()
+
+			key, err := d.targetFileName(a)
+			if err != nil {
+				return err
+			}
+			if d.Resume {
+				if existing, ok := man.Get(a.PublicID); ok {
+					want := manifest.Entry{PublicID: a.PublicID, Version: a.Version, Bytes: a.Bytes}
+					if existing.Matches(want) {
+						mu.Lock()
+						skipped++
+						mu.Unlock()
+						continue
+					}
+				}
+			}
+			url, err := d.assetURL(a)
+			if err != nil {
+				return err
+			}
+
+			mu.Lock()
+			byName[a.AssetID] = a
+			mu.Unlock()
+			job := transfer.Asset{Name: a.AssetID, URL: url, Key: key, Size: int64(a.Bytes)}
+			tracker.AddTotal(job.Size)
+			select {
+			case jobs <- job:
+			case <-gctx.Done():
+				return gctx.Err()
+			}
+
+			if d.Derived {
+				derivedJobs, err := d.derivedJobs(gctx, a, key)
+				if err != nil {
+					return err
+				}
+				for _, dj := range derivedJobs {
+					tracker.AddTotal(dj.Size)
+					select {
+					case jobs <- dj:
+					case <-gctx.Done():
+						return gctx.Err()
+					}
+				}
+			}
 		}
-		completed <- asset
+		return nil
+	})
+
+	var (
+		completed int
+		failed    []string
+	)
+	mgr := transfer.NewManager(store, d.Concurrency)
+	g.Go(func() error {
+		return mgr.Run(gctx, jobs, func(a transfer.Asset, cumulative int64) {
+			tracker.Report(a.Name, a.Size, cumulative)
+		}, func(r transfer.Result) {
+			tracker.Done(r.Asset.Name)
+
+			mu.Lock()
+			defer mu.Unlock()
+			completed++
+			if r.Err != nil {
+				failed = append(failed, fmt.Sprintf("%s: %s", r.Asset.Name, r.Err))
+				return
+			}
+			asset, ok := byName[r.Asset.Name]
+			if !ok {
+				// A derived asset job: not tracked in the manifest, since it
+				// isn't a standalone resource to resume or prune by itself.
+				return
+			}
+			man.Set(manifest.Entry{
+				PublicID: asset.PublicID,
+				Version:  asset.Version,
+				Bytes:    asset.Bytes,
+				ETag:     r.ETag,
+				Path:     r.Asset.Key,
+			})
+		})
+	})
+
+	if err := g.Wait(); err != nil {
+		return fmt.Errorf("dump failed: %w", err)
 	}
-}
 
-// Dump all assets from cloudinary to local folder
-func (d *Downloader) Dump(ctx context.Context) error {
-	if err := d.init(); err != nil {
-		return err
+	if d.Resume && skipped > 0 {
+		log.Printf("Skipping %d asset(s) already up to date.\n", skipped)
 	}
-	log.Printf("Collecting information about assets...\n")
-	assets, err := d.collectAllAssets(ctx)
-	if err != nil {
-		return fmt.Errorf("unable to collect information about assets: %w", err)
-	}
-	log.Printf("Got %d assets with a total size of %s.\n", len(assets), humanize.Bytes(uint64(assets.TotalSize())))
-	pending := make(chan api.BriefAssetResult, 1)
-	completedAssets := make(chan api.BriefAssetResult, 1)
-	finished := make(chan struct{})
-	go func(assets assetList, pending chan api.BriefAssetResult) {
-		for idx := range assets {
-			pending <- assets[idx]
-		}
-		close(pending)
-	}(assets, pending)
-	go func(allAssets assetList, completedAssets chan api.BriefAssetResult) {
-		completed := assetList{}
-		lT := time.Now()
-		for asset := range completedAssets {
-			completed = append(completed, asset)
-			if time.Since(lT) > time.Second {
-				log.Printf(
-					"Completed %3.1f%%. Downloaded %d/%d files, %s/%s\n",
-					float64(len(completed))/float64(len(allAssets))*100,
-					len(completed),
-					len(allAssets),
-					humanize.Bytes(uint64(completed.TotalSize())),
-					humanize.Bytes(uint64(allAssets.TotalSize())),
-				)
-				lT = time.Now()
+	if d.Resume && d.Prune {
+		for _, id := range man.PublicIDs() {
+			if _, stillRemote := remotePublicIDs[id]; stillRemote {
+				continue
 			}
-			if len(completed) == len(allAssets) {
-				break
+			entry, _ := man.Get(id)
+			if entry.Path != "" {
+				if err := store.Delete(ctx, entry.Path); err != nil {
+					log.Printf("Failed to prune %s: %s\n", entry.Path, err)
+				}
 			}
+			man.Remove(id)
 		}
-		close(finished)
-	}(assets, completedAssets)
-	for i := 0; i < d.Concurrency; i++ {
-		go d.worker(pending, completedAssets)
 	}
-	<-finished
+
+	log.Printf("Completed %d/%d transfers.\n", completed-len(failed), completed)
+	if err := man.Save(); err != nil {
+		log.Printf("Failed to save manifest: %s\n", err)
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%d asset(s) failed to download:\n%s", len(failed), joinLines(failed))
+	}
 	return nil
 }
 
+func joinLines(lines []string) string {
+	var b bytes.Buffer
+	for _, l := range lines {
+		b.WriteString("  - ")
+		b.WriteString(l)
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
 func init() {
 	flag.Parse()
 }
@@ -179,11 +409,20 @@ func main() {
 		flag.PrintDefaults()
 		os.Exit(1)
 	}
-	ctx := context.Background()
-	(&Downloader{
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if err := (&Downloader{
 		CloudinaryURL:                *cloudinaryURL,
 		TargetFolder:                 *targetFolder,
+		Target:                       *target,
 		TargetFileNameTemplateString: *targetNameTemplate,
 		Concurrency:                  *concurrency,
-	}).Dump(ctx)
+		Resume:                       *resume,
+		Prune:                        *prune,
+		Silent:                       *silent,
+		NoProgressBars:               *noProgressBars,
+		Derived:                      *derived,
+	}).Dump(ctx); err != nil {
+		log.Fatal(err)
+	}
 }
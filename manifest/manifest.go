@@ -0,0 +1,99 @@
+// Package manifest tracks which assets have already been downloaded so
+// that repeated runs of the dump tool can resume instead of starting over.
+package manifest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// FileName is the name of the manifest file created inside the target
+// folder.
+const FileName = ".cloudinary-dump.json"
+
+// Entry records the state of a single asset as of its last successful
+// download.
+type Entry struct {
+	PublicID string `json:"public_id"`
+	Version  int    `json:"version"`
+	Bytes    int    `json:"bytes"`
+	// ETag is the remote ETag observed for the download, if the server sent
+	// one. It is recorded for informational/debugging purposes only: the
+	// Cloudinary listing API this is compared against doesn't expose an
+	// ETag, so Matches can't use it to decide whether to resume.
+	ETag string `json:"etag,omitempty"`
+	// Path is the storage key the asset was written to, so a pruning pass
+	// can find it without recomputing the name template.
+	Path string `json:"path"`
+}
+
+// Matches reports whether other describes the same asset content as e,
+// i.e. a re-download can be skipped. Resume is decided by Version and
+// Bytes alone: ETag isn't available from the listing API this is compared
+// against, so it can't be part of the match.
+func (e Entry) Matches(other Entry) bool {
+	return e.Version == other.Version && e.Bytes == other.Bytes
+}
+
+// Manifest is a set of Entry records keyed by PublicID, persisted as a JSON
+// file at the path it was loaded from.
+type Manifest struct {
+	path    string
+	entries map[string]Entry
+}
+
+// Load reads the manifest at path. A missing file is not an error: it
+// yields an empty Manifest ready to be populated and saved.
+func Load(path string) (*Manifest, error) {
+	m := &Manifest{path: path, entries: map[string]Entry{}}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest %s: %w", path, err)
+	}
+	if err := json.Unmarshal(data, &m.entries); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest %s: %w", path, err)
+	}
+	return m, nil
+}
+
+// Get returns the recorded entry for publicID, if any.
+func (m *Manifest) Get(publicID string) (Entry, bool) {
+	e, ok := m.entries[publicID]
+	return e, ok
+}
+
+// Set records e as the current state of its asset.
+func (m *Manifest) Set(e Entry) {
+	m.entries[e.PublicID] = e
+}
+
+// Remove drops any recorded entry for publicID.
+func (m *Manifest) Remove(publicID string) {
+	delete(m.entries, publicID)
+}
+
+// PublicIDs returns the public IDs currently recorded in the manifest.
+func (m *Manifest) PublicIDs() []string {
+	ids := make([]string, 0, len(m.entries))
+	for id := range m.entries {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// Save writes the manifest back to its file.
+func (m *Manifest) Save() error {
+	data, err := json.MarshalIndent(m.entries, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode manifest: %w", err)
+	}
+	if err := os.WriteFile(m.path, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write manifest %s: %w", m.path, err)
+	}
+	return nil
+}
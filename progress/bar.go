@@ -0,0 +1,98 @@
+package progress
+
+import (
+	"sync"
+
+	"github.com/vbauerster/mpb/v8"
+	"github.com/vbauerster/mpb/v8/decor"
+)
+
+// barTracker renders a top-level bar for total bytes transferred, plus one
+// sub-bar per asset currently downloading, with throughput and ETA.
+type barTracker struct {
+	mu         sync.Mutex
+	p          *mpb.Progress
+	total      *mpb.Bar
+	totalBytes int64
+	bars       map[string]*assetBar
+}
+
+type assetBar struct {
+	bar      *mpb.Bar
+	size     int64
+	lastRead int64
+}
+
+func newBarTracker() *barTracker {
+	p := mpb.New(mpb.WithWidth(40))
+	total := p.AddBar(0,
+		mpb.PrependDecorators(decor.Name("total", decor.WC{W: len("total") + 1, C: decor.DindentRight})),
+		mpb.AppendDecorators(
+			decor.CountersKiloByte("% .1f / % .1f"),
+			decor.Name(" "),
+			decor.EwmaSpeed(decor.SizeB1024(0), "% .1f/s", 30),
+			decor.Name(" "),
+			decor.EwmaETA(decor.ET_STYLE_GO, 30),
+		),
+	)
+	return &barTracker{p: p, total: total, bars: map[string]*assetBar{}}
+}
+
+func (t *barTracker) AddTotal(bytes int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.totalBytes += bytes
+	t.total.SetTotal(t.totalBytes, false)
+}
+
+func (t *barTracker) Report(name string, size, cumulative int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	b, ok := t.bars[name]
+	if !ok {
+		b = &assetBar{
+			size: size,
+			bar: t.p.AddBar(size,
+				mpb.PrependDecorators(decor.Name(name, decor.WCSyncSpaceR)),
+				mpb.AppendDecorators(decor.CountersKiloByte("% .1f / % .1f")),
+				mpb.BarRemoveOnComplete(),
+			),
+		}
+		t.bars[name] = b
+	}
+	delta := cumulative - b.lastRead
+	b.lastRead = cumulative
+	if delta > 0 {
+		b.bar.IncrBy(int(delta))
+		t.total.IncrBy(int(delta))
+	}
+}
+
+func (t *barTracker) Done(name string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	b, ok := t.bars[name]
+	if !ok {
+		return
+	}
+	if remaining := b.size - b.lastRead; remaining > 0 {
+		b.bar.IncrBy(int(remaining))
+		t.total.IncrBy(int(remaining))
+	}
+	if b.size <= 0 {
+		// A bar of unknown size never completes by itself; see Close.
+		b.bar.SetTotal(-1, true)
+	}
+	delete(t.bars, name)
+}
+
+func (t *barTracker) Close() {
+	t.mu.Lock()
+	// A bar built with total<=0 (the initial state before any AddTotal call)
+	// only auto-completes once its total has been fixed via a SetTotal call
+	// with trigger=true; otherwise Wait blocks forever waiting for an
+	// increment that will never arrive.
+	t.total.SetTotal(t.totalBytes, true)
+	t.mu.Unlock()
+	t.p.Wait()
+}
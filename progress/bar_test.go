@@ -0,0 +1,24 @@
+package progress
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBarTrackerCloseCompletes(t *testing.T) {
+	bt := newBarTracker()
+	bt.AddTotal(100)
+	bt.Report("a", 100, 50)
+	bt.Report("a", 100, 100)
+	bt.Done("a")
+	done := make(chan struct{})
+	go func() {
+		bt.Close()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close did not return")
+	}
+}
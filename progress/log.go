@@ -0,0 +1,60 @@
+package progress
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/dustin/go-humanize"
+)
+
+// logTracker reports progress as plain, once-per-second log lines. It is
+// used when stderr isn't a terminal, or -no-progress was requested.
+type logTracker struct {
+	mu          sync.Mutex
+	total       int64
+	transferred int64
+	lastRead    map[string]int64
+	completed   int
+	lastLog     time.Time
+}
+
+func newLogTracker() *logTracker {
+	return &logTracker{lastRead: map[string]int64{}}
+}
+
+func (t *logTracker) AddTotal(bytes int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.total += bytes
+}
+
+func (t *logTracker) Report(name string, _, cumulative int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delta := cumulative - t.lastRead[name]
+	t.lastRead[name] = cumulative
+	t.transferred += delta
+	if time.Since(t.lastLog) > time.Second {
+		t.logLocked()
+		t.lastLog = time.Now()
+	}
+}
+
+func (t *logTracker) Done(name string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.completed++
+	delete(t.lastRead, name)
+}
+
+func (t *logTracker) Close() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.logLocked()
+}
+
+func (t *logTracker) logLocked() {
+	log.Printf("Downloaded %s/%s, %d asset(s) completed.\n",
+		humanize.Bytes(uint64(t.transferred)), humanize.Bytes(uint64(t.total)), t.completed)
+}
@@ -0,0 +1,48 @@
+// Package progress reports the state of an in-flight dump to the user: a
+// live multi-bar display when attached to a terminal, or plain periodic
+// log lines otherwise.
+package progress
+
+import (
+	"os"
+
+	"golang.org/x/term"
+)
+
+// Tracker reports transfer progress for a batch of named, sized assets
+// downloading concurrently. The total byte count isn't known up front: it
+// grows as assets are discovered by a streaming producer, so it is built up
+// with AddTotal rather than fixed at construction time.
+type Tracker interface {
+	// AddTotal grows the known total by bytes, as assets are discovered.
+	AddTotal(bytes int64)
+	// Report records the cumulative bytes downloaded so far for a named,
+	// sized asset. It may be called many times for the same name as the
+	// download progresses.
+	Report(name string, size, cumulative int64)
+	// Done marks name as finished.
+	Done(name string)
+	// Close stops the tracker, flushing any remaining output.
+	Close()
+}
+
+// New returns a Tracker appropriate for the given settings. silent
+// suppresses all progress output. noProgress, or stderr not being a
+// terminal, falls back to plain periodic log lines instead of live bars.
+func New(silent, noProgress bool) Tracker {
+	switch {
+	case silent:
+		return noopTracker{}
+	case noProgress || !term.IsTerminal(int(os.Stderr.Fd())):
+		return newLogTracker()
+	default:
+		return newBarTracker()
+	}
+}
+
+type noopTracker struct{}
+
+func (noopTracker) AddTotal(int64)              {}
+func (noopTracker) Report(string, int64, int64) {}
+func (noopTracker) Done(string)                 {}
+func (noopTracker) Close()                      {}
@@ -0,0 +1,82 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/kurin/blazer/b2"
+)
+
+// B2 stores assets as objects in a Backblaze B2 bucket, under Prefix.
+// Credentials are read from the B2_ACCOUNT_ID and B2_APPLICATION_KEY
+// environment variables.
+type B2 struct {
+	Bucket string
+	Prefix string
+	bucket *b2.Bucket
+}
+
+// NewB2 returns a B2 backend for bucket.
+func NewB2(bucket, prefix string) (*B2, error) {
+	client, err := b2.NewClient(context.Background(), os.Getenv("B2_ACCOUNT_ID"), os.Getenv("B2_APPLICATION_KEY"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create B2 client: %w", err)
+	}
+	bkt, err := client.Bucket(context.Background(), bucket)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open B2 bucket %s: %w", bucket, err)
+	}
+	return &B2{Bucket: bucket, Prefix: prefix, bucket: bkt}, nil
+}
+
+func (b *B2) key(key string) string {
+	if b.Prefix == "" {
+		return key
+	}
+	return b.Prefix + "/" + key
+}
+
+func (b *B2) Exists(ctx context.Context, key string) (bool, Meta, error) {
+	obj := b.bucket.Object(b.key(key))
+	attrs, err := obj.Attrs(ctx)
+	if b2.IsNotExist(err) {
+		return false, Meta{}, nil
+	}
+	if err != nil {
+		return false, Meta{}, fmt.Errorf("failed to stat %s in b2://%s: %w", key, b.Bucket, err)
+	}
+	return true, Meta{Size: attrs.Size}, nil
+}
+
+func (b *B2) Put(ctx context.Context, key string, r io.Reader, meta Meta) error {
+	w := b.bucket.Object(b.key(key)).NewWriter(ctx)
+	if _, err := io.Copy(w, r); err != nil {
+		w.Close()
+		return fmt.Errorf("failed to upload %s to b2://%s: %w", key, b.Bucket, err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to finalize %s in b2://%s: %w", key, b.Bucket, err)
+	}
+	return nil
+}
+
+func (b *B2) Delete(ctx context.Context, key string) error {
+	if err := b.bucket.Object(b.key(key)).Delete(ctx); err != nil && !b2.IsNotExist(err) {
+		return fmt.Errorf("failed to delete %s from b2://%s: %w", key, b.Bucket, err)
+	}
+	return nil
+}
+
+func (b *B2) List(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	iter := b.bucket.List(ctx, b2.ListPrefix(b.key(prefix)))
+	for iter.Next() {
+		keys = append(keys, iter.Object().Name())
+	}
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list b2://%s/%s: %w", b.Bucket, prefix, err)
+	}
+	return keys, nil
+}
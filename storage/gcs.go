@@ -0,0 +1,87 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/iterator"
+)
+
+// GCS stores assets as objects in a Google Cloud Storage bucket, under
+// Prefix.
+type GCS struct {
+	Bucket string
+	Prefix string
+	client *storage.Client
+}
+
+// NewGCS returns a GCS backend for bucket, using application default
+// credentials.
+func NewGCS(bucket, prefix string) (*GCS, error) {
+	client, err := storage.NewClient(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCS client: %w", err)
+	}
+	return &GCS{Bucket: bucket, Prefix: prefix, client: client}, nil
+}
+
+func (g *GCS) key(key string) string {
+	if g.Prefix == "" {
+		return key
+	}
+	return g.Prefix + "/" + key
+}
+
+func (g *GCS) object(key string) *storage.ObjectHandle {
+	return g.client.Bucket(g.Bucket).Object(g.key(key))
+}
+
+func (g *GCS) Exists(ctx context.Context, key string) (bool, Meta, error) {
+	attrs, err := g.object(key).Attrs(ctx)
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return false, Meta{}, nil
+	}
+	if err != nil {
+		return false, Meta{}, err
+	}
+	return true, Meta{Size: attrs.Size, ETag: attrs.Etag}, nil
+}
+
+func (g *GCS) Put(ctx context.Context, key string, r io.Reader, meta Meta) error {
+	w := g.object(key).NewWriter(ctx)
+	if _, err := io.Copy(w, r); err != nil {
+		w.Close()
+		return fmt.Errorf("failed to upload %s to gs://%s: %w", key, g.Bucket, err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to finalize %s in gs://%s: %w", key, g.Bucket, err)
+	}
+	return nil
+}
+
+func (g *GCS) Delete(ctx context.Context, key string) error {
+	err := g.object(key).Delete(ctx)
+	if err != nil && !errors.Is(err, storage.ErrObjectNotExist) {
+		return fmt.Errorf("failed to delete %s from gs://%s: %w", key, g.Bucket, err)
+	}
+	return nil
+}
+
+func (g *GCS) List(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	it := g.client.Bucket(g.Bucket).Objects(ctx, &storage.Query{Prefix: g.key(prefix)})
+	for {
+		attrs, err := it.Next()
+		if errors.Is(err, iterator.Done) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to list gs://%s/%s: %w", g.Bucket, prefix, err)
+		}
+		keys = append(keys, attrs.Name)
+	}
+	return keys, nil
+}
@@ -0,0 +1,97 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// S3 stores assets as objects in an S3 bucket, under Prefix.
+type S3 struct {
+	Bucket string
+	Prefix string
+	client *s3.Client
+}
+
+// NewS3 returns an S3 backend for bucket, using credentials from the
+// environment/shared config the same way the AWS CLI does.
+func NewS3(bucket, prefix string) (*S3, error) {
+	cfg, err := config.LoadDefaultConfig(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+	return &S3{Bucket: bucket, Prefix: prefix, client: s3.NewFromConfig(cfg)}, nil
+}
+
+func (s *S3) key(key string) string {
+	if s.Prefix == "" {
+		return key
+	}
+	return s.Prefix + "/" + key
+}
+
+func (s *S3) Exists(ctx context.Context, key string) (bool, Meta, error) {
+	out, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(s.Bucket),
+		Key:    aws.String(s.key(key)),
+	})
+	var notFound *types.NotFound
+	if errors.As(err, &notFound) {
+		return false, Meta{}, nil
+	}
+	if err != nil {
+		return false, Meta{}, err
+	}
+	meta := Meta{Size: aws.ToInt64(out.ContentLength)}
+	if out.ETag != nil {
+		meta.ETag = *out.ETag
+	}
+	return true, meta, nil
+}
+
+func (s *S3) Put(ctx context.Context, key string, r io.Reader, meta Meta) error {
+	_, err := s.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(s.Bucket),
+		Key:    aws.String(s.key(key)),
+		Body:   r,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to upload %s to s3://%s: %w", key, s.Bucket, err)
+	}
+	return nil
+}
+
+func (s *S3) Delete(ctx context.Context, key string) error {
+	_, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s.Bucket),
+		Key:    aws.String(s.key(key)),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete %s from s3://%s: %w", key, s.Bucket, err)
+	}
+	return nil
+}
+
+func (s *S3) List(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	paginator := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.Bucket),
+		Prefix: aws.String(s.key(prefix)),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list s3://%s/%s: %w", s.Bucket, prefix, err)
+		}
+		for _, obj := range page.Contents {
+			keys = append(keys, aws.ToString(obj.Key))
+		}
+	}
+	return keys, nil
+}
@@ -0,0 +1,77 @@
+// Package storage abstracts where downloaded assets end up, so the dump
+// tool can write straight to object storage instead of staging everything
+// on local disk first.
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+)
+
+// Meta carries metadata alongside a stored object.
+type Meta struct {
+	Size int64
+	ETag string
+}
+
+// Storage is a destination assets can be written to and listed from. Keys
+// are slash-separated paths relative to whatever root the Storage was
+// opened with.
+type Storage interface {
+	// Exists reports whether key is already present, and its metadata if so.
+	Exists(ctx context.Context, key string) (bool, Meta, error)
+	// Put streams r to key, overwriting any existing object.
+	Put(ctx context.Context, key string, r io.Reader, meta Meta) error
+	// List returns all keys stored under prefix.
+	List(ctx context.Context, prefix string) ([]string, error)
+	// Delete removes key. It is not an error if key doesn't exist.
+	Delete(ctx context.Context, key string) error
+}
+
+// Resumable is implemented by backends that can persist a partially
+// written object and be resumed later, letting the transfer manager issue
+// a Range request instead of re-downloading from the start. Backends that
+// don't implement it are always downloaded in full.
+type Resumable interface {
+	// PartialMeta returns the size and ETag of key's partial object, or a
+	// zero Meta if there is none. The ETag is whatever was last passed to
+	// WritePartial, so a caller can pin a resumed Range request to it (e.g.
+	// via If-Range) instead of blindly appending to bytes that may belong
+	// to a different remote version of the object.
+	PartialMeta(ctx context.Context, key string) (Meta, error)
+	// WritePartial appends r to key's partial object starting at offset,
+	// recording meta.ETag for a later PartialMeta to return. offset 0
+	// writes from scratch, discarding the existing partial object first.
+	WritePartial(ctx context.Context, key string, offset int64, meta Meta, r io.Reader) error
+	// CommitPartial promotes key's partial object to its final form.
+	CommitPartial(ctx context.Context, key string, meta Meta) error
+}
+
+// Open parses target, a location like "dump", "./dump", "s3://bucket/prefix",
+// "gs://bucket/prefix" or "b2://bucket/prefix", and returns the matching
+// Storage implementation.
+func Open(target string) (Storage, error) {
+	u, err := url.Parse(target)
+	if err != nil || u.Scheme == "" || len(u.Scheme) == 1 {
+		// No scheme, or a single letter that's really a Windows drive
+		// letter: treat the whole thing as a local path.
+		return NewLocal(target), nil
+	}
+	bucket := u.Host
+	prefix := strings.TrimPrefix(u.Path, "/")
+	switch u.Scheme {
+	case "file":
+		return NewLocal(prefix), nil
+	case "s3":
+		return NewS3(bucket, prefix)
+	case "gs":
+		return NewGCS(bucket, prefix)
+	case "b2":
+		return NewB2(bucket, prefix)
+	default:
+		return nil, fmt.Errorf("unsupported storage target %q: unknown scheme %q", target, u.Scheme)
+	}
+}
@@ -0,0 +1,160 @@
+package transfer
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+
+	"github.com/jlisicki/cloudinary-dump/storage"
+)
+
+// downloadAsset downloads a into m.Store under a.Key, resuming a partial
+// object if the backend supports it (storage.Resumable) and a.SHA256 is not
+// set. On success it verifies the size (and checksum, if a.SHA256 is set).
+func (m *Manager) downloadAsset(ctx context.Context, a Asset, onProgress func(Asset, int64)) (string, error) {
+	resumable, canResume := m.Store.(storage.Resumable)
+
+	var startOffset int64
+	var resumeETag string
+	if canResume {
+		meta, err := resumable.PartialMeta(ctx, a.Key)
+		if err != nil {
+			return "", fmt.Errorf("failed to inspect partial download for %s: %w", a.Name, err)
+		}
+		// Only resume if the partial is pinned to the remote version it was
+		// downloaded from: without an ETag to send as If-Range, a same-named
+		// asset that changed since the partial was left behind would splice
+		// its new bytes onto the old ones, corrupting the file undetectably.
+		// A checksummed asset is always fetched from the start, since only
+		// the bytes received here are hashed.
+		if meta.Size > 0 && meta.ETag != "" && a.SHA256 == "" {
+			startOffset = meta.Size
+			resumeETag = meta.ETag
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, a.URL, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to build request for %s: %w", a.Name, err)
+	}
+	if startOffset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", startOffset))
+		req.Header.Set("If-Range", resumeETag)
+	}
+
+	resp, err := m.client().Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to download %s: %w", a.Name, err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		if startOffset > 0 {
+			// If-Range didn't match: the object changed since the partial
+			// was written, and the server sent the whole thing back instead
+			// of continuing. Discard the stale partial and restart clean.
+			resp.Body.Close()
+			return m.downloadFresh(ctx, a, onProgress)
+		}
+	case http.StatusPartialContent:
+		// resuming as requested
+	case http.StatusRequestedRangeNotSatisfiable:
+		// the partial object already covers the whole asset; restart from
+		// scratch and let the size/checksum check below catch any mismatch.
+		resp.Body.Close()
+		return m.downloadFresh(ctx, a, onProgress)
+	default:
+		return "", &httpStatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+	etag := resp.Header.Get("ETag")
+
+	var body io.Reader = &progressReader{r: resp.Body, asset: a, onProgress: onProgress}
+	var checksum hash.Hash
+	if a.SHA256 != "" {
+		checksum = sha256.New()
+		body = io.TeeReader(body, checksum)
+	}
+
+	if canResume {
+		if err := resumable.WritePartial(ctx, a.Key, startOffset, storage.Meta{ETag: etag}, body); err != nil {
+			return "", fmt.Errorf("failed to write %s: %w", a.Name, err)
+		}
+	} else if err := m.Store.Put(ctx, a.Key, body, storage.Meta{Size: a.Size, ETag: etag}); err != nil {
+		return "", fmt.Errorf("failed to write %s: %w", a.Name, err)
+	}
+
+	if checksum != nil {
+		if sum := hex.EncodeToString(checksum.Sum(nil)); sum != a.SHA256 {
+			return "", fmt.Errorf("checksum mismatch for %s: expected %s, got %s", a.Name, a.SHA256, sum)
+		}
+	}
+
+	if canResume {
+		if err := resumable.CommitPartial(ctx, a.Key, storage.Meta{Size: a.Size, ETag: etag}); err != nil {
+			return "", fmt.Errorf("failed to finalize %s: %w", a.Name, err)
+		}
+	}
+
+	if err := m.verify(ctx, a); err != nil {
+		return "", err
+	}
+	return etag, nil
+}
+
+// downloadFresh discards any partial object for a and retries the download
+// from scratch.
+func (m *Manager) downloadFresh(ctx context.Context, a Asset, onProgress func(Asset, int64)) (string, error) {
+	if resumable, ok := m.Store.(storage.Resumable); ok {
+		if err := resumable.WritePartial(ctx, a.Key, 0, storage.Meta{}, http.NoBody); err != nil {
+			return "", fmt.Errorf("failed to discard stale partial download for %s: %w", a.Name, err)
+		}
+	}
+	return m.downloadAsset(ctx, a, onProgress)
+}
+
+// verify checks the stored object's size against a.Size.
+func (m *Manager) verify(ctx context.Context, a Asset) error {
+	exists, meta, err := m.Store.Exists(ctx, a.Key)
+	if err != nil {
+		return fmt.Errorf("failed to verify %s: %w", a.Name, err)
+	}
+	if !exists {
+		return fmt.Errorf("asset %s missing from storage after download", a.Name)
+	}
+	if a.Size > 0 && meta.Size != a.Size {
+		return fmt.Errorf("size mismatch for %s: expected %d bytes, got %d", a.Name, a.Size, meta.Size)
+	}
+	return nil
+}
+
+func (m *Manager) client() *http.Client {
+	if m.Client != nil {
+		return m.Client
+	}
+	return http.DefaultClient
+}
+
+// progressReader wraps an io.Reader and reports cumulative bytes read via
+// onProgress, if set.
+type progressReader struct {
+	r          io.Reader
+	asset      Asset
+	read       int64
+	onProgress func(Asset, int64)
+}
+
+func (p *progressReader) Read(buf []byte) (int, error) {
+	n, err := p.r.Read(buf)
+	if n > 0 {
+		p.read += int64(n)
+		if p.onProgress != nil {
+			p.onProgress(p.asset, p.read)
+		}
+	}
+	return n, err
+}
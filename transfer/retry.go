@@ -0,0 +1,69 @@
+package transfer
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net"
+	"net/http"
+	"time"
+)
+
+// downloadWithRetry retries downloadAsset on transient failures using
+// exponential backoff with jitter, up to m.MaxRetries times after the first
+// attempt.
+func (m *Manager) downloadWithRetry(ctx context.Context, a Asset, onProgress func(Asset, int64)) (string, error) {
+	var lastErr error
+	for attempt := 0; attempt <= m.MaxRetries; attempt++ {
+		if attempt > 0 {
+			delay := backoffDelay(attempt, m.BaseBackoff, m.MaxBackoff)
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+				return "", ctx.Err()
+			}
+		}
+		etag, err := m.downloadAsset(ctx, a, onProgress)
+		if err == nil {
+			return etag, nil
+		}
+		lastErr = err
+		if !isRetryable(err) {
+			return "", err
+		}
+	}
+	return "", lastErr
+}
+
+// backoffDelay returns an exponential backoff duration with full jitter,
+// capped at max.
+func backoffDelay(attempt int, base, max time.Duration) time.Duration {
+	d := base << attempt
+	if d <= 0 || d > max {
+		d = max
+	}
+	return time.Duration(rand.Int63n(int64(d) + 1))
+}
+
+// httpStatusError carries the response status code for a non-2xx response
+// so isRetryable can decide whether it is worth retrying.
+type httpStatusError struct {
+	StatusCode int
+	Status     string
+}
+
+func (e *httpStatusError) Error() string {
+	return "unexpected response status: " + e.Status
+}
+
+func isRetryable(err error) bool {
+	var statusErr *httpStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode == http.StatusTooManyRequests || statusErr.StatusCode >= 500
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	return errors.Is(err, context.DeadlineExceeded)
+}
@@ -0,0 +1,97 @@
+package transfer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/jlisicki/cloudinary-dump/storage"
+)
+
+func TestIsRetryable(t *testing.T) {
+	cases := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"too many requests", &httpStatusError{StatusCode: http.StatusTooManyRequests}, true},
+		{"server error", &httpStatusError{StatusCode: http.StatusServiceUnavailable}, true},
+		{"not found", &httpStatusError{StatusCode: http.StatusNotFound}, false},
+		{"deadline exceeded", context.DeadlineExceeded, true},
+		{"net error", &fakeNetError{}, true},
+		{"plain error", errors.New("boom"), false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := isRetryable(c.err); got != c.want {
+				t.Errorf("isRetryable(%v) = %v, want %v", c.err, got, c.want)
+			}
+		})
+	}
+}
+
+type fakeNetError struct{}
+
+func (*fakeNetError) Error() string   { return "fake net error" }
+func (*fakeNetError) Timeout() bool   { return true }
+func (*fakeNetError) Temporary() bool { return true }
+
+func TestDownloadWithRetrySucceedsAfterTransientFailures(t *testing.T) {
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, "payload")
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	m := NewManager(storage.NewLocal(dir), 1)
+	m.BaseBackoff = time.Millisecond
+	m.MaxBackoff = 5 * time.Millisecond
+
+	a := Asset{Name: "a", URL: srv.URL, Key: "a.txt", Size: int64(len("payload"))}
+	if _, err := m.downloadWithRetry(context.Background(), a, nil); err != nil {
+		t.Fatalf("downloadWithRetry: %v", err)
+	}
+	if requests != 3 {
+		t.Fatalf("requests = %d, want 3", requests)
+	}
+	got, err := os.ReadFile(dir + "/a.txt")
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if string(got) != "payload" {
+		t.Fatalf("got %q, want %q", got, "payload")
+	}
+}
+
+func TestDownloadWithRetryGivesUpOnNonRetryable(t *testing.T) {
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	m := NewManager(storage.NewLocal(dir), 1)
+	m.BaseBackoff = time.Millisecond
+	m.MaxBackoff = 5 * time.Millisecond
+
+	a := Asset{Name: "a", URL: srv.URL, Key: "a.txt"}
+	if _, err := m.downloadWithRetry(context.Background(), a, nil); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	if requests != 1 {
+		t.Fatalf("requests = %d, want 1 (non-retryable failure shouldn't retry)", requests)
+	}
+}
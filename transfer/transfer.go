@@ -0,0 +1,179 @@
+// Package transfer implements a small transfer manager for downloading a
+// batch of remote assets into a storage backend: a bounded pool of workers
+// pulls jobs from a queue, retries transient failures with backoff, and,
+// where the backend supports it, resumes partial downloads instead of
+// starting over.
+package transfer
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+	"golang.org/x/sync/semaphore"
+
+	"github.com/jlisicki/cloudinary-dump/storage"
+)
+
+// defaultMaxInFlightBytes bounds the total size of downloads in progress at
+// once, so a batch of very large assets can't balloon memory and disk I/O
+// regardless of Concurrency.
+const defaultMaxInFlightBytes = 512 << 20 // 512MiB
+
+// Asset describes a single file to be transferred. It is intentionally
+// decoupled from any particular remote API so the manager can be reused
+// outside of Cloudinary-specific callers.
+type Asset struct {
+	// Name identifies the asset in logs and errors.
+	Name string
+	// URL is the location to download the asset from.
+	URL string
+	// Key is the storage key the asset should end up at.
+	Key string
+	// Size is the expected size in bytes, used to validate the download
+	// and to weigh it against MaxInFlightBytes. Zero means unknown.
+	Size int64
+	// SHA256, if set, is verified against the downloaded file's checksum.
+	SHA256 string
+}
+
+// Result is the outcome of transferring a single Asset.
+type Result struct {
+	Asset Asset
+	// ETag is the remote ETag observed for the download, if the server
+	// sent one. Useful for callers that want to detect remote changes on
+	// a later run.
+	ETag string
+	Err  error
+}
+
+// Manager owns a pool of workers that download Assets concurrently into a
+// Storage backend.
+type Manager struct {
+	// Store is where downloaded assets are written. Required.
+	Store storage.Storage
+	// Client is used to perform HTTP requests. Defaults to http.DefaultClient.
+	Client *http.Client
+	// Concurrency is the number of workers downloading in parallel.
+	Concurrency int
+	// MaxInFlightBytes caps the combined Size of assets being downloaded at
+	// once. Zero means defaultMaxInFlightBytes.
+	MaxInFlightBytes int64
+	// MaxRetries is the number of retry attempts for a transient failure
+	// before the asset is reported as failed.
+	MaxRetries int
+	// BaseBackoff and MaxBackoff bound the exponential backoff-with-jitter
+	// delay between retries.
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+}
+
+// NewManager returns a Manager with sensible defaults for concurrency,
+// retries and backoff, writing downloaded assets to store.
+func NewManager(store storage.Storage, concurrency int) *Manager {
+	return &Manager{
+		Store:            store,
+		Client:           http.DefaultClient,
+		Concurrency:      concurrency,
+		MaxInFlightBytes: defaultMaxInFlightBytes,
+		MaxRetries:       5,
+		BaseBackoff:      500 * time.Millisecond,
+		MaxBackoff:       30 * time.Second,
+	}
+}
+
+// Run downloads assets read from jobs until it is closed, reporting each
+// Result to onResult as its download finishes. A failure downloading one
+// asset is reported through onResult and does not stop the others.
+//
+// If ctx is cancelled, Run stops starting new downloads, reports every job
+// still waiting in the channel as an aborted Result (rather than silently
+// dropping it) and returns ctx.Err() once every already-started download has
+// unwound. Callers must still close jobs once they stop sending to it, the
+// same as on an uncancelled run, or draining would block forever.
+//
+// Workers are bounded two ways at once: at most Concurrency downloads run
+// at a time, and their combined Size may not exceed MaxInFlightBytes, so a
+// handful of very large assets can't starve memory and disk I/O the way a
+// plain worker-count limit would.
+func (m *Manager) Run(ctx context.Context, jobs <-chan Asset, onProgress func(Asset, int64), onResult func(Result)) error {
+	g, gctx := errgroup.WithContext(ctx)
+
+	concurrency := int64(m.Concurrency)
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	slotSem := semaphore.NewWeighted(concurrency)
+	byteSem := semaphore.NewWeighted(m.maxInFlightBytes())
+
+	for {
+		select {
+		case a, ok := <-jobs:
+			if !ok {
+				return g.Wait()
+			}
+			if err := slotSem.Acquire(gctx, 1); err != nil {
+				onResult(abortedResult(a, err))
+				m.abort(jobs, onResult, err)
+				g.Wait()
+				return err
+			}
+			weight := m.weight(a)
+			if err := byteSem.Acquire(gctx, weight); err != nil {
+				slotSem.Release(1)
+				onResult(abortedResult(a, err))
+				m.abort(jobs, onResult, err)
+				g.Wait()
+				return err
+			}
+			g.Go(func() error {
+				defer slotSem.Release(1)
+				defer byteSem.Release(weight)
+				etag, err := m.downloadWithRetry(gctx, a, onProgress)
+				onResult(Result{Asset: a, ETag: etag, Err: err})
+				return nil
+			})
+		case <-gctx.Done():
+			err := gctx.Err()
+			m.abort(jobs, onResult, err)
+			g.Wait()
+			return err
+		}
+	}
+}
+
+// abort reports every job still waiting in jobs as aborted, draining the
+// channel so its producer's eventual close doesn't block on a full buffer.
+func (m *Manager) abort(jobs <-chan Asset, onResult func(Result), err error) {
+	for a := range jobs {
+		onResult(abortedResult(a, err))
+	}
+}
+
+func abortedResult(a Asset, err error) Result {
+	return Result{Asset: a, Err: fmt.Errorf("download aborted: %w", err)}
+}
+
+func (m *Manager) maxInFlightBytes() int64 {
+	if m.MaxInFlightBytes > 0 {
+		return m.MaxInFlightBytes
+	}
+	return defaultMaxInFlightBytes
+}
+
+// weight returns how much of the MaxInFlightBytes budget a should occupy
+// while downloading. Assets of unknown size claim a single byte so they
+// still count against Concurrency without starving the budget.
+func (m *Manager) weight(a Asset) int64 {
+	max := m.maxInFlightBytes()
+	switch {
+	case a.Size <= 0:
+		return 1
+	case a.Size > max:
+		return max
+	default:
+		return a.Size
+	}
+}
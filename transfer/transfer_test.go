@@ -0,0 +1,40 @@
+package transfer
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/jlisicki/cloudinary-dump/storage"
+)
+
+func TestRunAbortsQueuedJobsOnCancellation(t *testing.T) {
+	dir := t.TempDir()
+	m := NewManager(storage.NewLocal(dir), 1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	const n = 3
+	jobs := make(chan Asset, n)
+	for i := 0; i < n; i++ {
+		jobs <- Asset{Name: fmt.Sprintf("a%d", i), Key: fmt.Sprintf("a%d.txt", i)}
+	}
+	close(jobs)
+
+	var results []Result
+	err := m.Run(ctx, jobs, func(Asset, int64) {}, func(r Result) {
+		results = append(results, r)
+	})
+	if err == nil {
+		t.Fatal("expected a non-nil error from a cancelled Run")
+	}
+	if len(results) != n {
+		t.Fatalf("got %d results, want %d: every queued job should be reported, not dropped", len(results), n)
+	}
+	for _, r := range results {
+		if r.Err == nil {
+			t.Errorf("result for %s has nil Err, want an aborted error", r.Asset.Name)
+		}
+	}
+}